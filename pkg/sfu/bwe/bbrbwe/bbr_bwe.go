@@ -0,0 +1,570 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bbrbwe implements a BBR-inspired bandwidth estimator. It is a
+// sibling of remotebwe: both satisfy bwe.BWE, so either can be selected as
+// the SFU's congestion controller without any change to downstream
+// allocation code.
+package bbrbwe
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+	"github.com/livekit/livekit-server/pkg/sfu/ccutils"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils/mono"
+)
+
+// ---------------------------------------------------------------------------
+
+type bbrPhase int
+
+const (
+	bbrPhaseStartup bbrPhase = iota
+	bbrPhaseDrain
+	bbrPhaseProbeBW
+	bbrPhaseProbeRTT
+)
+
+func (p bbrPhase) String() string {
+	switch p {
+	case bbrPhaseStartup:
+		return "startup"
+	case bbrPhaseDrain:
+		return "drain"
+	case bbrPhaseProbeBW:
+		return "probe_bw"
+	case bbrPhaseProbeRTT:
+		return "probe_rtt"
+	default:
+		return "unknown"
+	}
+}
+
+// gain applied to BtlBw * RTprop to size cwnd/pacing rate in each phase.
+const (
+	startupGain = 2.77 // 2 / ln(2)
+	drainGain   = 1.0 / startupGain
+)
+
+// pacingGainCycle is the 8-phase PROBE_BW gain cycle, rotated by a random
+// starting offset per BBR so that competing flows don't synchronize.
+var pacingGainCycle = [8]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+const (
+	btlBwWindowRTTs     = 10
+	rtPropWindow        = 10 * time.Second
+	probeRTTInterval    = 10 * time.Second
+	probeRTTMinDuration = 200 * time.Millisecond
+	probeRTTCwndMSS     = 4
+	mss                 = 1200
+
+	startupGrowthThreshold = 1.25
+	startupRoundsToExit    = 3
+	// startupRequiredStagnantWindows is how many consecutive
+	// startupRoundsToExit-sized windows must show insufficient BtlBw growth
+	// before STARTUP gives up and moves to DRAIN. A single slow window is
+	// common noise; three in a row is a real plateau.
+	startupRequiredStagnantWindows = 3
+)
+
+// ---------------------------------------------------------------------------
+
+type BBRBWEConfig struct {
+	MSS int `yaml:"mss,omitempty"`
+}
+
+var DefaultBBRBWEConfig = BBRBWEConfig{
+	MSS: mss,
+}
+
+// ---------------------------------------------------------------------------
+
+type BBRBWEParams struct {
+	Config BBRBWEConfig
+	Logger logger.Logger
+}
+
+// deliverySample is one observation of delivered bytes over an interval,
+// used to update the max-filter over delivery rate (BtlBw).
+type deliverySample struct {
+	rtt         time.Duration // RTT observed with this sample; the BtlBw window counts samples, not this value
+	deliveryBps float64
+}
+
+type rttSample struct {
+	at  time.Time
+	rtt time.Duration
+}
+
+const sendHistorySize = 2048
+
+// SentPacketInfo is the send-side record needed to pair a TWCC feedback
+// report's sequence numbers back to when/how-large each packet was on the
+// wire. Shape matches remotebwe.SentPacketInfo, but BBRBWE keeps its own
+// send history so it has no dependency on remotebwe as its sibling BWE.
+type SentPacketInfo struct {
+	SequenceNumber uint16
+	SentAt         time.Time
+	Size           int
+}
+
+type sentPacketInfo = SentPacketInfo
+
+// BBRBWE is a BBR-inspired congestion controller: it maintains a max-filter
+// over delivery-rate samples (BtlBw) and a min-filter over RTT samples
+// (RTprop), and cycles STARTUP -> DRAIN -> PROBE_BW, periodically diverting
+// to PROBE_RTT to keep RTprop fresh.
+type BBRBWE struct {
+	bwe.NullBWE
+
+	params BBRBWEParams
+
+	lock sync.RWMutex
+
+	phase      bbrPhase
+	roundCount int
+
+	deliverySamples []deliverySample
+	btlBw           float64 // bits/sec, same convention as AddDeliverySample's deliveryBps
+
+	rttSamples []rttSample
+	rtProp     time.Duration
+
+	lastREMBAt time.Time
+
+	sendHistory [sendHistorySize]sentPacketInfo
+	sendValid   [sendHistorySize]bool
+
+	startupBtlBwAtRoundStart float64
+	startupStagnantRounds    int
+
+	drainEnteredAt        time.Time
+	drainStartBacklogBits float64
+
+	cycleIndex     int
+	cycleStartedAt time.Time
+
+	probeRTTEnteredAt   time.Time
+	lastProbeRTTAt      time.Time
+	probeRTTDeadlineMet bool
+
+	committedChannelCapacity int64
+
+	isInProbe bool
+
+	congestionState           bwe.CongestionState
+	congestionStateSwitchedAt time.Time
+
+	bweListener bwe.BWEListener
+}
+
+func NewBBRBWE(params BBRBWEParams) *BBRBWE {
+	b := &BBRBWE{
+		params: params,
+	}
+	b.Reset()
+	return b
+}
+
+func (b *BBRBWE) SetBWEListener(bweListener bwe.BWEListener) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bweListener = bweListener
+}
+
+func (b *BBRBWE) getBWEListener() bwe.BWEListener {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.bweListener
+}
+
+func (b *BBRBWE) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.phase = bbrPhaseStartup
+	b.roundCount = 0
+	b.deliverySamples = nil
+	b.btlBw = 0
+	b.rttSamples = nil
+	b.rtProp = 0
+	b.startupBtlBwAtRoundStart = 0
+	b.startupStagnantRounds = 0
+	b.drainEnteredAt = time.Time{}
+	b.drainStartBacklogBits = 0
+	b.cycleIndex = 0
+	b.cycleStartedAt = mono.Now()
+	b.lastProbeRTTAt = mono.Now()
+	b.committedChannelCapacity = 100_000_000
+	b.isInProbe = false
+	b.lastREMBAt = time.Time{}
+	b.sendHistory = [sendHistorySize]sentPacketInfo{}
+	b.sendValid = [sendHistorySize]bool{}
+
+	b.congestionState = bwe.CongestionStateNone
+	b.congestionStateSwitchedAt = mono.Now()
+}
+
+func (b *BBRBWE) CongestionState() bwe.CongestionState {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.congestionState
+}
+
+// AddDeliverySample feeds a delivery-rate observation (bits acked over the
+// receive-time span they were acked in) into the BtlBw max-filter directly.
+// HandleREMB and HandleTransportCC below are the two real feedback paths
+// that derive a sample and call this; most callers should use one of those
+// instead of computing a sample by hand.
+func (b *BBRBWE) AddDeliverySample(deliveryBps float64, rtt time.Duration) {
+	b.lock.Lock()
+	now := mono.Now()
+	b.deliverySamples = append(b.deliverySamples, deliverySample{rtt: rtt, deliveryBps: deliveryBps})
+	b.pruneDeliverySamples()
+	b.addRTTSample(now, rtt)
+
+	b.roundCount++
+	capacityBefore := b.committedChannelCapacity
+	shouldNotify, state, capacity := b.updatePhase()
+	b.lock.Unlock()
+
+	// Notify on every committed-capacity change, not just qualitative
+	// congestion-state flips: STARTUP and steady PROBE_BW cruising both map
+	// to CongestionStateNone throughout, so gating purely on state change
+	// would silently drop the growing BtlBw estimate for most of the
+	// estimator's operating life.
+	if shouldNotify || capacity != capacityBefore {
+		if bweListener := b.getBWEListener(); bweListener != nil {
+			bweListener.OnCongestionStateChange(state, capacity)
+		}
+	}
+}
+
+// HandleREMB is the REMB-only fallback feedback path: REMB already reports
+// the receiver's estimate of usable throughput, so (lacking per-packet
+// acked-bytes information) it is fed directly as a delivery-rate sample,
+// paired with the interval since the last REMB as an RTT proxy until a real
+// RTT sample (from HandleTransportCC, or rtProp itself once seeded) is
+// available. HandleTransportCC below gives a much tighter sample when TWCC
+// is available and should be preferred.
+func (b *BBRBWE) HandleREMB(receivedEstimate int64, _expectedBandwidthUsage int64, _sentPackets uint32, _repeatedNacks uint32) {
+	b.lock.Lock()
+	now := mono.Now()
+	rtt := b.rtProp
+	if rtt == 0 {
+		if !b.lastREMBAt.IsZero() {
+			rtt = now.Sub(b.lastREMBAt)
+		} else {
+			rtt = probeRTTMinDuration
+		}
+	}
+	b.lastREMBAt = now
+	b.lock.Unlock()
+
+	b.AddDeliverySample(float64(receivedEstimate), rtt)
+}
+
+// RecordSentPacket registers a packet's send-side timing/size so a later
+// HandleTransportCC call can pair TWCC feedback back to it.
+func (b *BBRBWE) RecordSentPacket(info SentPacketInfo) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	idx := info.SequenceNumber % sendHistorySize
+	b.sendHistory[idx] = info
+	b.sendValid[idx] = true
+}
+
+// HandleTransportCC derives a delivery-rate/RTT sample directly from TWCC
+// feedback (acked bytes over the receive-time span they arrived in, and the
+// last acked packet's send-to-receive latency as an RTT proxy) and feeds it
+// into AddDeliverySample. This is the tighter alternative to the
+// HandleREMB fallback above.
+func (b *BBRBWE) HandleTransportCC(feedback *rtcp.TransportLayerCC) {
+	processedAt := mono.Now()
+	deliveryBps, rtt, ok := b.deliverySampleFromTWCC(feedback, processedAt)
+	if !ok {
+		return
+	}
+	b.AddDeliverySample(deliveryBps, rtt)
+}
+
+// deliverySampleFromTWCC derives a delivery-rate sample from acked bytes
+// over the receive-time span they arrived in (a relative span, so the
+// feedback's receiver-chosen reference epoch cancels out), and an RTT
+// proxy from processedAt (when this feedback was handled, our own clock)
+// minus the last acked packet's send time (also our own clock) -- recvTime
+// itself is anchored to the receiver's arbitrary reference time and must
+// never be diffed directly against a local wall-clock timestamp.
+func (b *BBRBWE) deliverySampleFromTWCC(feedback *rtcp.TransportLayerCC, processedAt time.Time) (float64, time.Duration, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	statuses := feedback.PacketChunks
+	if len(statuses) == 0 {
+		return 0, 0, false
+	}
+
+	type arrival struct {
+		sentAt   time.Time
+		size     int
+		recvTime time.Time
+	}
+
+	refTime := time.Unix(0, int64(feedback.ReferenceTime)*64*int64(time.Millisecond))
+	deltaIdx := 0
+	sn := feedback.BaseSequenceNumber
+
+	var arrivals []arrival
+
+	walkSymbols := func(symbols []rtcp.TypeTCC) {
+		for _, sym := range symbols {
+			if sym == rtcp.TypeTCCPacketNotReceived {
+				sn++
+				continue
+			}
+			if deltaIdx >= len(feedback.RecvDeltas) {
+				sn++
+				continue
+			}
+			delta := feedback.RecvDeltas[deltaIdx]
+			deltaIdx++
+
+			recvTime := refTime.Add(time.Duration(delta.Delta) * time.Microsecond)
+			idx := sn % sendHistorySize
+			if b.sendValid[idx] && b.sendHistory[idx].SequenceNumber == sn {
+				spi := b.sendHistory[idx]
+				arrivals = append(arrivals, arrival{sentAt: spi.SentAt, size: spi.Size, recvTime: recvTime})
+			}
+			sn++
+		}
+	}
+
+	for _, chunk := range statuses {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			symbols := make([]rtcp.TypeTCC, c.RunLength)
+			for i := range symbols {
+				symbols[i] = c.PacketStatusSymbol
+			}
+			walkSymbols(symbols)
+		case *rtcp.StatusVectorChunk:
+			walkSymbols(c.SymbolList)
+		}
+	}
+
+	if len(arrivals) == 0 {
+		return 0, 0, false
+	}
+
+	first, last := arrivals[0], arrivals[len(arrivals)-1]
+	recvSpan := last.recvTime.Sub(first.recvTime)
+	var bytesAcked int
+	for _, a := range arrivals {
+		bytesAcked += a.size
+	}
+
+	if recvSpan <= 0 {
+		return 0, 0, false
+	}
+
+	deliveryBps := float64(bytesAcked*8) / recvSpan.Seconds()
+	rtt := processedAt.Sub(last.sentAt)
+	if rtt <= 0 {
+		rtt = probeRTTMinDuration
+	}
+	return deliveryBps, rtt, true
+}
+
+func (b *BBRBWE) pruneDeliverySamples() {
+	if len(b.deliverySamples) > btlBwWindowRTTs {
+		b.deliverySamples = b.deliverySamples[len(b.deliverySamples)-btlBwWindowRTTs:]
+	}
+
+	var maxBps float64
+	for _, s := range b.deliverySamples {
+		if s.deliveryBps > maxBps {
+			maxBps = s.deliveryBps
+		}
+	}
+	b.btlBw = maxBps
+}
+
+func (b *BBRBWE) addRTTSample(at time.Time, rtt time.Duration) {
+	b.rttSamples = append(b.rttSamples, rttSample{at: at, rtt: rtt})
+
+	cutoff := at.Add(-rtPropWindow)
+	pruned := b.rttSamples[:0]
+	minRTT := time.Duration(0)
+	for _, s := range b.rttSamples {
+		if s.at.Before(cutoff) {
+			continue
+		}
+		pruned = append(pruned, s)
+		if minRTT == 0 || s.rtt < minRTT {
+			minRTT = s.rtt
+		}
+	}
+	b.rttSamples = pruned
+	b.rtProp = minRTT
+}
+
+// bdp is the bandwidth-delay-product: BtlBw * RTprop, in bits.
+func (b *BBRBWE) bdp() float64 {
+	return b.btlBw * b.rtProp.Seconds()
+}
+
+func (b *BBRBWE) pacingGain() float64 {
+	switch b.phase {
+	case bbrPhaseStartup:
+		return startupGain
+	case bbrPhaseDrain:
+		return drainGain
+	case bbrPhaseProbeBW:
+		return pacingGainCycle[b.cycleIndex]
+	case bbrPhaseProbeRTT:
+		return 1.0
+	default:
+		return 1.0
+	}
+}
+
+// updatePhase advances the BBR state machine by one round and maps the
+// result onto bwe.CongestionState so downstream listeners need no changes.
+func (b *BBRBWE) updatePhase() (bool, bwe.CongestionState, int64) {
+	now := mono.Now()
+
+	switch b.phase {
+	case bbrPhaseStartup:
+		if b.roundCount == 1 {
+			b.startupBtlBwAtRoundStart = b.btlBw
+		} else if b.roundCount%startupRoundsToExit == 0 {
+			growth := 0.0
+			if b.startupBtlBwAtRoundStart > 0 {
+				growth = (b.btlBw - b.startupBtlBwAtRoundStart) / b.startupBtlBwAtRoundStart
+			}
+			if growth < startupGrowthThreshold-1.0 {
+				b.startupStagnantRounds++
+			} else {
+				b.startupStagnantRounds = 0
+			}
+			b.startupBtlBwAtRoundStart = b.btlBw
+
+			if b.startupStagnantRounds >= startupRequiredStagnantWindows {
+				b.phase = bbrPhaseDrain
+				// STARTUP paces at startupGain*BtlBw, so it queues roughly
+				// (startupGain-1)*BDP bits beyond what the path can drain in
+				// one RTT; DRAIN paces at drainGain*BtlBw until that backlog
+				// is estimated worked off, not just for one feedback round.
+				b.drainEnteredAt = now
+				b.drainStartBacklogBits = b.bdp() * (startupGain - 1)
+				b.params.Logger.Infow("bbr bwe: exiting startup, entering drain", "btlBw", b.btlBw, "rtProp", b.rtProp, "backlogBits", b.drainStartBacklogBits)
+			}
+		}
+
+	case bbrPhaseDrain:
+		elapsed := now.Sub(b.drainEnteredAt).Seconds()
+		// surplus capacity freed by pacing below BtlBw is what works off the
+		// backlog queued during STARTUP.
+		drainRateBps := b.btlBw * (1 - drainGain)
+		remainingBacklogBits := b.drainStartBacklogBits - elapsed*drainRateBps
+		if remainingBacklogBits <= 0 || b.bdp() == 0 {
+			b.phase = bbrPhaseProbeBW
+			b.cycleIndex = rand.Intn(len(pacingGainCycle))
+			b.cycleStartedAt = now
+			b.params.Logger.Infow("bbr bwe: exiting drain, entering probe_bw", "btlBw", b.btlBw)
+		}
+
+	case bbrPhaseProbeBW:
+		if now.Sub(b.cycleStartedAt) >= b.rtProp {
+			b.cycleIndex = (b.cycleIndex + 1) % len(pacingGainCycle)
+			b.cycleStartedAt = now
+		}
+		if now.Sub(b.lastProbeRTTAt) >= probeRTTInterval {
+			b.phase = bbrPhaseProbeRTT
+			b.probeRTTEnteredAt = now
+			b.probeRTTDeadlineMet = false
+			b.params.Logger.Infow("bbr bwe: entering probe_rtt")
+		}
+
+	case bbrPhaseProbeRTT:
+		if now.Sub(b.probeRTTEnteredAt) >= probeRTTMinDuration {
+			b.probeRTTDeadlineMet = true
+		}
+		if b.probeRTTDeadlineMet {
+			b.phase = bbrPhaseProbeBW
+			b.cycleIndex = rand.Intn(len(pacingGainCycle))
+			b.cycleStartedAt = now
+			b.lastProbeRTTAt = now
+			b.params.Logger.Infow("bbr bwe: exiting probe_rtt, resuming probe_bw")
+		}
+	}
+
+	if b.phase == bbrPhaseProbeRTT {
+		// shrink the committed capacity to probeRTTCwndMSS worth of MSS for
+		// the duration of PROBE_RTT, so downstream pacing actually drains
+		// queued packets enough to let RTprop be re-measured cleanly.
+		b.committedChannelCapacity = int64(probeRTTCwndMSS * b.params.Config.MSS * 8)
+	} else {
+		b.committedChannelCapacity = int64(b.btlBw)
+	}
+
+	newState := bwe.CongestionStateNone
+	switch {
+	case b.phase == bbrPhaseDrain:
+		newState = bwe.CongestionStateCongestedHangover
+	case b.phase == bbrPhaseProbeBW && b.pacingGain() < 1.0:
+		newState = bwe.CongestionStateCongested
+	}
+
+	shouldNotify := newState != b.congestionState
+	if shouldNotify {
+		b.params.Logger.Infow("bbr bwe: congestion state change", "from", b.congestionState, "to", newState, "phase", b.phase)
+		b.congestionState = newState
+		b.congestionStateSwitchedAt = now
+	}
+
+	return shouldNotify, b.congestionState, b.committedChannelCapacity
+}
+
+func (b *BBRBWE) ProbeClusterStarting(pci ccutils.ProbeClusterInfo) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.isInProbe = true
+}
+
+func (b *BBRBWE) ProbeClusterDone(_pci ccutils.ProbeClusterInfo) (bwe.ProbeSignal, int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.isInProbe = false
+
+	if b.congestionState != bwe.CongestionStateNone {
+		return bwe.ProbeSignalCongesting, b.committedChannelCapacity
+	}
+	if len(b.deliverySamples) == 0 {
+		return bwe.ProbeSignalInconclusive, b.committedChannelCapacity
+	}
+	return bwe.ProbeSignalClearing, b.committedChannelCapacity
+}
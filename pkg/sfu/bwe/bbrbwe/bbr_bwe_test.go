@@ -0,0 +1,112 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bbrbwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils/mono"
+)
+
+func newTestBBRBWE() *BBRBWE {
+	return NewBBRBWE(BBRBWEParams{
+		Config: DefaultBBRBWEConfig,
+		Logger: logger.GetLogger(),
+	})
+}
+
+// TestAddDeliverySampleCommitsBitsPerSecond pins that committedChannelCapacity
+// is reported in bits/sec, matching the deliveryBps unit callers feed in,
+// with no hidden byte<->bit conversion along the way.
+func TestAddDeliverySampleCommitsBitsPerSecond(t *testing.T) {
+	b := newTestBBRBWE()
+
+	const deliveryBps = 2_000_000.0
+	b.AddDeliverySample(deliveryBps, 50*time.Millisecond)
+
+	got := b.committedChannelCapacity
+	if got != int64(deliveryBps) {
+		t.Fatalf("expected committed capacity to equal the bits/sec delivery sample %v, got %d", deliveryBps, got)
+	}
+}
+
+// TestStartupRequiresConsecutiveStagnantWindows pins that STARTUP only exits
+// to DRAIN after startupRequiredStagnantWindows consecutive windows with
+// insufficient BtlBw growth, and that a single growing window resets the
+// streak.
+func TestStartupRequiresConsecutiveStagnantWindows(t *testing.T) {
+	b := newTestBBRBWE()
+
+	feedWindow := func(deliveryBps float64) {
+		for i := 0; i < startupRoundsToExit; i++ {
+			b.AddDeliverySample(deliveryBps, 20*time.Millisecond)
+		}
+	}
+
+	// two stagnant windows: flat BtlBw at the same value, one window short of
+	// startupRequiredStagnantWindows, should not be enough to leave STARTUP.
+	feedWindow(1_000_000)
+	feedWindow(1_000_000)
+	if b.phase != bbrPhaseStartup {
+		t.Fatalf("expected to remain in startup after two stagnant windows, got phase %v", b.phase)
+	}
+
+	// a growing window resets the streak.
+	feedWindow(5_000_000)
+	if b.phase != bbrPhaseStartup {
+		t.Fatalf("expected to remain in startup after a growing window, got phase %v", b.phase)
+	}
+
+	// now three consecutive stagnant windows in a row should exit startup.
+	feedWindow(5_000_000)
+	feedWindow(5_000_000)
+	feedWindow(5_000_000)
+	if b.phase == bbrPhaseStartup {
+		t.Fatalf("expected startup to exit after 3 consecutive stagnant windows, still in %v", b.phase)
+	}
+}
+
+// TestProbeRTTShrinksCommittedCapacity pins that entering PROBE_RTT actually
+// constrains committedChannelCapacity to probeRTTCwndMSS worth of MSS,
+// rather than leaving it at the unconstrained BtlBw estimate.
+func TestProbeRTTShrinksCommittedCapacity(t *testing.T) {
+	b := newTestBBRBWE()
+	b.lock.Lock()
+	b.phase = bbrPhaseProbeRTT
+	b.probeRTTEnteredAt = mono.Now() // just entered, not yet past probeRTTMinDuration
+	b.probeRTTDeadlineMet = false
+	b.btlBw = 10_000_000
+	b.lock.Unlock()
+
+	_, _, capacity := b.updatePhase()
+
+	want := int64(probeRTTCwndMSS * b.params.Config.MSS * 8)
+	if capacity != want {
+		t.Fatalf("expected PROBE_RTT to shrink committed capacity to %d, got %d", want, capacity)
+	}
+}
+
+// TestHandleREMBReachesDeliveryFilter pins that the REMB-only fallback path
+// is actually wired into the BtlBw filter.
+func TestHandleREMBReachesDeliveryFilter(t *testing.T) {
+	b := newTestBBRBWE()
+	b.HandleREMB(3_000_000, 3_000_000, 100, 0)
+
+	if b.btlBw != 3_000_000 {
+		t.Fatalf("expected HandleREMB to feed the delivery-rate filter, btlBw=%v", b.btlBw)
+	}
+}
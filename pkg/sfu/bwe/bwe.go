@@ -0,0 +1,125 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bwe defines the interfaces shared by every bandwidth estimator
+// implementation (remotebwe, bbrbwe, ...) and their listeners, so the rest
+// of the SFU can depend on bwe.BWE/bwe.BWEListener without caring which
+// estimator is selected.
+package bwe
+
+import (
+	"github.com/livekit/livekit-server/pkg/sfu/bwe/fairness"
+	"github.com/livekit/livekit-server/pkg/sfu/ccutils"
+)
+
+// CongestionState is the estimator's current verdict on the channel.
+type CongestionState int
+
+const (
+	CongestionStateNone CongestionState = iota
+	CongestionStateCongested
+	CongestionStateCongestedHangover
+)
+
+func (c CongestionState) String() string {
+	switch c {
+	case CongestionStateNone:
+		return "none"
+	case CongestionStateCongested:
+		return "congested"
+	case CongestionStateCongestedHangover:
+		return "congested_hangover"
+	default:
+		return "unknown"
+	}
+}
+
+// ProbeSignal is the verdict ProbeClusterDone returns once a probe cluster
+// has finished, telling the caller whether it's safe to believe the probed
+// capacity.
+type ProbeSignal int
+
+const (
+	ProbeSignalInconclusive ProbeSignal = iota
+	ProbeSignalCongesting
+	ProbeSignalClearing
+)
+
+func (p ProbeSignal) String() string {
+	switch p {
+	case ProbeSignalInconclusive:
+		return "inconclusive"
+	case ProbeSignalCongesting:
+		return "congesting"
+	case ProbeSignalClearing:
+		return "clearing"
+	default:
+		return "unknown"
+	}
+}
+
+// BWE is the interface every bandwidth estimator implementation (remotebwe,
+// bbrbwe, ...) satisfies, so the SFU can select one without the rest of the
+// stack caring which.
+type BWE interface {
+	SetBWEListener(bweListener BWEListener)
+	Reset()
+	CongestionState() CongestionState
+	HandleREMB(receivedEstimate int64, expectedBandwidthUsage int64, sentPackets uint32, repeatedNacks uint32)
+	ProbeClusterStarting(pci ccutils.ProbeClusterInfo)
+	ProbeClusterDone(pci ccutils.ProbeClusterInfo) (ProbeSignal, int64)
+}
+
+// BWEListener receives notifications from a BWE: congestion state/capacity
+// changes, and (for estimators that track per-track constraints) how the
+// latest committed capacity was split across tracks.
+type BWEListener interface {
+	// OnCongestionStateChange is called whenever the estimator's congestion
+	// state or committed channel capacity (bits/sec) changes.
+	OnCongestionStateChange(state CongestionState, committedChannelCapacity int64)
+
+	// OnTrackAllocations is called whenever committed capacity has been
+	// re-split across the estimator's current per-track constraints, with
+	// the resulting per-track allocation in bits/sec.
+	OnTrackAllocations(allocations map[fairness.TrackID]int64)
+}
+
+// NullBWE is embedded by BWE implementations to provide no-op defaults for
+// methods they don't otherwise need to override.
+type NullBWE struct{}
+
+func (NullBWE) SetBWEListener(_bweListener BWEListener) {}
+
+func (NullBWE) Reset() {}
+
+func (NullBWE) CongestionState() CongestionState { return CongestionStateNone }
+
+func (NullBWE) HandleREMB(_receivedEstimate int64, _expectedBandwidthUsage int64, _sentPackets uint32, _repeatedNacks uint32) {
+}
+
+func (NullBWE) ProbeClusterStarting(_pci ccutils.ProbeClusterInfo) {}
+
+func (NullBWE) ProbeClusterDone(_pci ccutils.ProbeClusterInfo) (ProbeSignal, int64) {
+	return ProbeSignalInconclusive, 0
+}
+
+// NullBWEListener is embedded by BWEListener implementations that only care
+// about a subset of callbacks, so adding a method here (like
+// OnTrackAllocations) doesn't break every existing listener.
+type NullBWEListener struct{}
+
+func (NullBWEListener) OnCongestionStateChange(_state CongestionState, _committedChannelCapacity int64) {
+}
+
+func (NullBWEListener) OnTrackAllocations(_allocations map[fairness.TrackID]int64) {}
@@ -0,0 +1,134 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fairness turns a single committed channel capacity into a
+// per-track allocation using weighted max-min (progressive-filling)
+// fairness, so operators can express priorities (e.g. screenshare weight=4)
+// declaratively instead of every caller re-implementing its own split of
+// bwe.BWEListener.OnCongestionStateChange's capacity.
+package fairness
+
+import "sort"
+
+// TrackID identifies a published track subscription being allocated
+// bandwidth.
+type TrackID string
+
+// Constraint is a track's bandwidth requirements and priority: it will
+// never be allocated less than MinBps (if capacity allows) or more than
+// MaxBps, and among unconstrained tracks, allocation is proportional to
+// Weight.
+type Constraint struct {
+	MinBps int64
+	MaxBps int64
+	Weight float64
+}
+
+// Allocate splits capacity across tracks using weighted max-min fairness:
+// tracks are filled in rounds, in increasing order of MaxBps/Weight, each
+// getting min(fairShare, MaxBps); saturated tracks (those hitting MaxBps)
+// are removed and the remaining capacity is re-split across what's left,
+// repeating until capacity is exhausted or every track is saturated.
+//
+// Tracks whose MinBps cannot be met because capacity is insufficient for
+// every track's minimum are still given a proportional share of capacity
+// rather than being starved to zero, since an SFU cannot simply refuse to
+// send a subscribed track.
+func Allocate(capacity int64, constraints map[TrackID]Constraint) map[TrackID]int64 {
+	allocation := make(map[TrackID]int64, len(constraints))
+	if capacity <= 0 || len(constraints) == 0 {
+		for id := range constraints {
+			allocation[id] = 0
+		}
+		return allocation
+	}
+
+	var minSum int64
+	ids := make([]TrackID, 0, len(constraints))
+	for id, c := range constraints {
+		minSum += c.MinBps
+		ids = append(ids, id)
+	}
+
+	if minSum > capacity {
+		// not enough to cover every minimum, scale everyone's minimum down
+		// proportionally rather than starving tracks arbitrarily.
+		scale := float64(capacity) / float64(minSum)
+		for _, id := range ids {
+			allocation[id] = int64(float64(constraints[id].MinBps) * scale)
+		}
+		return allocation
+	}
+
+	remaining := capacity - minSum
+	for _, id := range ids {
+		allocation[id] = constraints[id].MinBps
+	}
+
+	active := make([]TrackID, len(ids))
+	copy(active, ids)
+
+	for len(active) > 0 && remaining > 0 {
+		sort.Slice(active, func(i, j int) bool {
+			ci, cj := constraints[active[i]], constraints[active[j]]
+			return float64(ci.MaxBps)/weightOrOne(ci.Weight) < float64(cj.MaxBps)/weightOrOne(cj.Weight)
+		})
+
+		var weightSum float64
+		for _, id := range active {
+			weightSum += weightOrOne(constraints[id].Weight)
+		}
+		if weightSum <= 0 {
+			break
+		}
+
+		progressed := false
+		next := active[:0]
+		remainingAtRoundStart := remaining
+		for _, id := range active {
+			c := constraints[id]
+			share := float64(remainingAtRoundStart) / weightSum * weightOrOne(c.Weight)
+			headroom := c.MaxBps - allocation[id]
+			if share > float64(headroom) {
+				share = float64(headroom)
+			}
+			if share <= 0 {
+				continue
+			}
+
+			give := int64(share)
+			allocation[id] += give
+			remaining -= give
+			progressed = true
+
+			if allocation[id] < c.MaxBps {
+				next = append(next, id)
+			}
+		}
+		active = next
+
+		if !progressed {
+			break
+		}
+	}
+
+	return allocation
+}
+
+func weightOrOne(w float64) float64 {
+	if w <= 0 {
+		return 1
+	}
+	return w
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fairness
+
+import "testing"
+
+func TestAllocateNoConstraints(t *testing.T) {
+	if got := Allocate(1_000_000, nil); len(got) != 0 {
+		t.Fatalf("expected empty allocation, got %v", got)
+	}
+}
+
+func TestAllocateZeroCapacity(t *testing.T) {
+	constraints := map[TrackID]Constraint{
+		"a": {MinBps: 100, MaxBps: 1000, Weight: 1},
+	}
+	got := Allocate(0, constraints)
+	if got["a"] != 0 {
+		t.Fatalf("expected 0 capacity to allocate nothing, got %v", got)
+	}
+}
+
+func TestAllocateSingleTrackClampsToMax(t *testing.T) {
+	constraints := map[TrackID]Constraint{
+		"a": {MinBps: 0, MaxBps: 500_000, Weight: 1},
+	}
+	got := Allocate(1_000_000, constraints)
+	if got["a"] != 500_000 {
+		t.Fatalf("expected allocation clamped to MaxBps 500000, got %d", got["a"])
+	}
+}
+
+func TestAllocateWeightedSplitAmongUnconstrained(t *testing.T) {
+	constraints := map[TrackID]Constraint{
+		"audio": {MinBps: 0, MaxBps: 1_000_000, Weight: 1},
+		"video": {MinBps: 0, MaxBps: 1_000_000, Weight: 3},
+	}
+	got := Allocate(800_000, constraints)
+	if got["video"] <= got["audio"] {
+		t.Fatalf("expected higher-weight track to get more capacity, got audio=%d video=%d", got["audio"], got["video"])
+	}
+	// roughly a 1:3 split since neither hits its MaxBps.
+	ratio := float64(got["video"]) / float64(got["audio"])
+	if ratio < 2.5 || ratio > 3.5 {
+		t.Fatalf("expected video:audio ratio near 3, got %f (audio=%d video=%d)", ratio, got["audio"], got["video"])
+	}
+}
+
+func TestAllocateInsufficientForMinimumsScalesDown(t *testing.T) {
+	constraints := map[TrackID]Constraint{
+		"a": {MinBps: 600_000, MaxBps: 1_000_000, Weight: 1},
+		"b": {MinBps: 600_000, MaxBps: 1_000_000, Weight: 1},
+	}
+	got := Allocate(600_000, constraints)
+	total := got["a"] + got["b"]
+	if total > 600_000 {
+		t.Fatalf("allocation exceeded capacity: %d", total)
+	}
+	if got["a"] == 0 || got["b"] == 0 {
+		t.Fatalf("expected both tracks to still get a proportional share, got %v", got)
+	}
+}
+
+func TestAllocateNeverExceedsCapacity(t *testing.T) {
+	constraints := map[TrackID]Constraint{
+		"a": {MinBps: 100_000, MaxBps: 10_000_000, Weight: 1},
+		"b": {MinBps: 100_000, MaxBps: 10_000_000, Weight: 5},
+		"c": {MinBps: 100_000, MaxBps: 10_000_000, Weight: 2},
+	}
+	got := Allocate(2_000_000, constraints)
+	var total int64
+	for _, v := range got {
+		total += v
+	}
+	if total > 2_000_000 {
+		t.Fatalf("allocation total %d exceeds capacity 2000000", total)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pacer provides a token-bucket rate limiter used to cap the
+// instantaneous rate at which probe clusters inject packets, so a probe
+// cannot look like a burst of loss/delay to the rest of the congestion
+// detector purely from being sent in one shot.
+package pacer
+
+import (
+	"sync"
+	"time"
+)
+
+// Pacer is a token-bucket rate limiter parameterized by a target rate and a
+// burst allowance, modeled on the standard library's golang.org/x/time/rate
+// pattern but specialized for byte-sized reservations.
+type Pacer struct {
+	mu sync.Mutex
+
+	rateBps              float64
+	burstBytes           float64
+	notifyThresholdBytes float64
+
+	tokens     float64
+	lastRefill time.Time
+
+	lowTokens chan struct{}
+	notified  bool
+
+	reservedBytes int64
+	reservedSince time.Time
+	maxBurstBytes float64
+	stalls        int
+	stallDuration time.Duration
+}
+
+// NewPacer creates a Pacer that allows rateBps/8 bytes per second to be
+// reserved on average, bursting up to burstBytes. lowTokens fires (a single
+// buffered signal, coalesced if unread) whenever available tokens drop below
+// notifyThresholdBytes, so a caller can slow down or refill out of band.
+func NewPacer(rateBps float64, burstBytes float64, notifyThresholdBytes float64) *Pacer {
+	now := time.Now()
+	return &Pacer{
+		rateBps:              rateBps,
+		burstBytes:           burstBytes,
+		notifyThresholdBytes: notifyThresholdBytes,
+		tokens:               burstBytes,
+		lastRefill:           now,
+		reservedSince:        now,
+		lowTokens:            make(chan struct{}, 1),
+	}
+}
+
+// LowTokens returns a channel that receives a signal whenever available
+// tokens fall below notifyThresholdBytes.
+func (p *Pacer) LowTokens() <-chan struct{} {
+	return p.lowTokens
+}
+
+func (p *Pacer) refill(now time.Time) {
+	elapsed := now.Sub(p.lastRefill).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	p.lastRefill = now
+
+	p.tokens += elapsed * (p.rateBps / 8)
+	if p.tokens > p.burstBytes {
+		p.tokens = p.burstBytes
+	}
+}
+
+// Reserve accounts for nBytes being sent and returns how long the caller
+// should wait before sending them, 0 if they can go out immediately. It
+// always debits the token bucket, so back-to-back Reserve calls pace
+// correctly even if the caller doesn't actually wait.
+func (p *Pacer) Reserve(nBytes int) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.refill(now)
+
+	n := float64(nBytes)
+	var wait time.Duration
+	if n > p.tokens {
+		deficit := n - p.tokens
+		if p.rateBps > 0 {
+			wait = time.Duration(deficit / (p.rateBps / 8) * float64(time.Second))
+		}
+		p.stalls++
+		p.stallDuration += wait
+	}
+
+	// tokens may go negative: the debt is carried into the next Reserve's wait.
+	p.tokens -= n
+
+	p.reservedBytes += int64(nBytes)
+	if n > p.maxBurstBytes {
+		p.maxBurstBytes = n
+	}
+
+	if p.tokens < p.notifyThresholdBytes && !p.notified {
+		p.notified = true
+		select {
+		case p.lowTokens <- struct{}{}:
+		default:
+		}
+	} else if p.tokens >= p.notifyThresholdBytes {
+		p.notified = false
+	}
+
+	return wait
+}
+
+// Stats is a point-in-time summary of pacer activity since it was created,
+// reported by RemoteBWE alongside probe-done telemetry.
+type Stats struct {
+	AvgActualRateBps float64
+	MaxBurstBytes    int64
+	Stalls           int
+	StallDuration    time.Duration
+	UnusedTokens     int64
+}
+
+func (p *Pacer) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.reservedSince).Seconds()
+	var avgRate float64
+	if elapsed > 0 {
+		avgRate = float64(p.reservedBytes) * 8 / elapsed
+	}
+	unusedTokens := p.tokens
+	if unusedTokens < 0 {
+		unusedTokens = 0
+	}
+
+	return Stats{
+		AvgActualRateBps: avgRate,
+		MaxBurstBytes:    int64(p.maxBurstBytes),
+		Stalls:           p.stalls,
+		StallDuration:    p.stallDuration,
+		UnusedTokens:     int64(unusedTokens),
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveWithinBurstDoesNotWait(t *testing.T) {
+	p := NewPacer(8_000_000, 1500, 500)
+
+	if wait := p.Reserve(1000); wait != 0 {
+		t.Fatalf("expected no wait for a reservation within the initial burst, got %v", wait)
+	}
+}
+
+func TestReserveBeyondBurstWaits(t *testing.T) {
+	p := NewPacer(8_000_000, 1000, 500)
+
+	// first reservation drains most of the burst.
+	p.Reserve(900)
+	// second, immediately after, exceeds available tokens and must wait.
+	wait := p.Reserve(900)
+	if wait <= 0 {
+		t.Fatalf("expected a positive wait once the burst allowance is exhausted, got %v", wait)
+	}
+
+	stats := p.Stats()
+	if stats.Stalls != 1 {
+		t.Fatalf("expected 1 stall recorded, got %d", stats.Stalls)
+	}
+	if stats.StallDuration <= 0 {
+		t.Fatalf("expected stall duration to be recorded, got %v", stats.StallDuration)
+	}
+}
+
+func TestLowTokensFiresBelowThreshold(t *testing.T) {
+	p := NewPacer(8_000_000, 1000, 500)
+
+	p.Reserve(600) // drops tokens below the 500-byte notify threshold
+
+	select {
+	case <-p.LowTokens():
+	default:
+		t.Fatal("expected LowTokens to fire once tokens drop below notifyThresholdBytes")
+	}
+}
+
+func TestStatsReportsMaxBurstAndUnusedTokens(t *testing.T) {
+	p := NewPacer(8_000_000, 2000, 500)
+
+	p.Reserve(300)
+	p.Reserve(1200)
+
+	stats := p.Stats()
+	if stats.MaxBurstBytes != 1200 {
+		t.Fatalf("expected MaxBurstBytes to track the largest single reservation (1200), got %d", stats.MaxBurstBytes)
+	}
+	if stats.UnusedTokens < 0 {
+		t.Fatalf("expected non-negative unused tokens, got %d", stats.UnusedTokens)
+	}
+}
+
+func TestRefillRestoresTokensOverTime(t *testing.T) {
+	p := NewPacer(8_000_000, 1000, 500)
+
+	p.Reserve(1000) // drain the bucket entirely
+	time.Sleep(50 * time.Millisecond)
+
+	// at 1MBps (8_000_000 bps / 8), 50ms of refill is far more than the
+	// bucket holds, so it is back at its 1000-byte burst cap, comfortably
+	// enough to cover a small reservation without waiting.
+	if wait := p.Reserve(100); wait != 0 {
+		t.Fatalf("expected refill over 50ms to cover a 100 byte reservation, got wait %v", wait)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"fmt"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// ---------------------------------------------------------------------------
+
+type channelTrend int
+
+const (
+	channelTrendNeutral channelTrend = iota
+	channelTrendCongesting
+)
+
+func (c channelTrend) String() string {
+	switch c {
+	case channelTrendNeutral:
+		return "neutral"
+	case channelTrendCongesting:
+		return "congesting"
+	default:
+		return "unknown"
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+type channelCongestionReason int
+
+const (
+	channelCongestionReasonNone channelCongestionReason = iota
+	channelCongestionReasonEstimate
+	channelCongestionReasonLoss
+	// channelCongestionReasonDelay is reported when the TWCC one-way-delay
+	// gradient (see delayGradientFilter in transport_cc.go) indicates queuing
+	// delay is building up, independent of REMB or NACK ratio.
+	channelCongestionReasonDelay
+)
+
+func (c channelCongestionReason) String() string {
+	switch c {
+	case channelCongestionReasonNone:
+		return "none"
+	case channelCongestionReasonEstimate:
+		return "estimate"
+	case channelCongestionReasonLoss:
+		return "loss"
+	case channelCongestionReasonDelay:
+		return "delay"
+	default:
+		return "unknown"
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+type ChannelObserverConfig struct {
+	MinEstimateSamples             int     `yaml:"min_estimate_samples,omitempty"`
+	EstimateDownwardTrendThreshold float64 `yaml:"estimate_downward_trend_threshold,omitempty"`
+	NackWindowMinDuration          int     `yaml:"nack_window_min_duration_ms,omitempty"`
+	NackRatioThreshold             float64 `yaml:"nack_ratio_threshold,omitempty"`
+	DelayGradientThreshold         float64 `yaml:"delay_gradient_threshold,omitempty"`
+}
+
+var (
+	defaultChannelObserverConfigProbe = ChannelObserverConfig{
+		MinEstimateSamples:             3,
+		EstimateDownwardTrendThreshold: -0.6,
+		NackWindowMinDuration:          500,
+		NackRatioThreshold:             0.04,
+		DelayGradientThreshold:         0.2,
+	}
+
+	defaultChannelObserverConfigNonProbe = ChannelObserverConfig{
+		MinEstimateSamples:             8,
+		EstimateDownwardTrendThreshold: -0.6,
+		NackWindowMinDuration:          2000,
+		NackRatioThreshold:             0.08,
+		DelayGradientThreshold:         0.2,
+	}
+)
+
+// ---------------------------------------------------------------------------
+
+type channelObserverParams struct {
+	Name   string
+	Config ChannelObserverConfig
+}
+
+// channelObserver watches a stream of REMB estimates, NACK counts and
+// (when TWCC feedback is available) one-way-delay gradients, and reports
+// whether the channel looks like it is congesting, along with the reason.
+type channelObserver struct {
+	params channelObserverParams
+	logger logger.Logger
+
+	estimates       []int64
+	highestEstimate int64
+
+	packetsSent uint32
+	packetsLost uint32
+
+	delayGradient float64
+}
+
+func newChannelObserver(params channelObserverParams, logger logger.Logger) *channelObserver {
+	return &channelObserver{
+		params: params,
+		logger: logger,
+	}
+}
+
+func (c *channelObserver) SeedEstimate(estimate int64) {
+	c.estimates = append(c.estimates, estimate)
+	if estimate > c.highestEstimate {
+		c.highestEstimate = estimate
+	}
+}
+
+func (c *channelObserver) AddEstimate(estimate int64) {
+	c.estimates = append(c.estimates, estimate)
+	if estimate > c.highestEstimate {
+		c.highestEstimate = estimate
+	}
+}
+
+func (c *channelObserver) AddNack(sentPackets uint32, repeatedNacks uint32) {
+	c.packetsSent += sentPackets
+	c.packetsLost += repeatedNacks
+}
+
+// AddDelayGradient records the latest Kalman-filtered one-way-delay gradient
+// derived from TWCC feedback (see delayGradientFilter). A positive, growing
+// gradient means packets are arriving progressively later than they were
+// sent relative to each other, i.e. a queue is building somewhere on path.
+func (c *channelObserver) AddDelayGradient(gradient float64) {
+	c.delayGradient = gradient
+}
+
+func (c *channelObserver) HasEnoughEstimateSamples() bool {
+	return len(c.estimates) >= c.params.Config.MinEstimateSamples
+}
+
+func (c *channelObserver) GetHighestEstimate() int64 {
+	return c.highestEstimate
+}
+
+func (c *channelObserver) GetNackRatio() float64 {
+	if c.packetsSent == 0 {
+		return 0
+	}
+	return float64(c.packetsLost) / float64(c.packetsSent)
+}
+
+// GetTrend reports whether the observed channel is congesting and why. Loss
+// takes priority, then delay gradient, then the REMB estimate slope -- any
+// of the three can independently indicate congestion.
+func (c *channelObserver) GetTrend() (channelTrend, channelCongestionReason) {
+	if c.GetNackRatio() > c.params.Config.NackRatioThreshold {
+		return channelTrendCongesting, channelCongestionReasonLoss
+	}
+
+	if c.delayGradient > c.params.Config.DelayGradientThreshold {
+		return channelTrendCongesting, channelCongestionReasonDelay
+	}
+
+	if !c.HasEnoughEstimateSamples() {
+		return channelTrendNeutral, channelCongestionReasonNone
+	}
+
+	first := c.estimates[0]
+	last := c.estimates[len(c.estimates)-1]
+	if first == 0 {
+		return channelTrendNeutral, channelCongestionReasonNone
+	}
+
+	slope := float64(last-first) / float64(first)
+	if slope <= c.params.Config.EstimateDownwardTrendThreshold {
+		return channelTrendCongesting, channelCongestionReasonEstimate
+	}
+
+	return channelTrendNeutral, channelCongestionReasonNone
+}
+
+func (c *channelObserver) String() string {
+	return fmt.Sprintf(
+		"%s: estimates: %v, highestEstimate: %d, packetsSent: %d, packetsLost: %d, delayGradient: %.3f",
+		c.params.Name, c.estimates, c.highestEstimate, c.packetsSent, c.packetsLost, c.delayGradient,
+	)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils/mono"
+)
+
+// ControllerType selects the congestion-detection strategy RemoteBWE runs
+// behind the Controller interface.
+type ControllerType string
+
+const (
+	// ControllerTrend is the original REMB-trend + NACK-ratio detector.
+	ControllerTrend ControllerType = "trend"
+	// ControllerNewReno halves the committed capacity on loss episodes and
+	// additively increases it by one MSS-equivalent per RTT otherwise.
+	ControllerNewReno ControllerType = "new_reno"
+	// ControllerCubic grows the committed capacity along the CUBIC function
+	// from the capacity at the last congestion event.
+	ControllerCubic ControllerType = "cubic"
+)
+
+// ControllerSample is one observation fed to a Controller: the trend/reason
+// the channel observer currently reports, plus the raw REMB/NACK inputs the
+// loss-based controllers need directly.
+type ControllerSample struct {
+	Trend                      channelTrend
+	Reason                     channelCongestionReason
+	NackRatio                  float64
+	LastReceivedEstimate       int64
+	LastExpectedBandwidthUsage int64
+	IsInProbe                  bool
+}
+
+// Controller is the pluggable congestion-detection strategy behind
+// RemoteBWE. Every implementation reports into the same
+// bwe.BWEListener.OnCongestionStateChange contract, so swapping controllers
+// is invisible to the rest of the SFU.
+type Controller interface {
+	// OnSample processes one channel-observer update and reports whether the
+	// congestion state or committed capacity changed.
+	OnSample(sample ControllerSample) (shouldNotify bool, state bwe.CongestionState, committedCapacity int64)
+	CommittedCapacity() int64
+	State() bwe.CongestionState
+	Reset(seedCapacity int64)
+}
+
+func newController(controllerType ControllerType, config RemoteBWEConfig, logger logger.Logger) Controller {
+	switch controllerType {
+	case ControllerNewReno:
+		return newNewRenoController(logger)
+	case ControllerCubic:
+		return newCubicController(logger)
+	default:
+		return newTrendController(config, logger)
+	}
+}
+
+// ---------------------------------------------------------------------------
+
+// trendController is RemoteBWE's original detector: a three-state machine
+// (None -> Congested -> CongestedHangover -> None) driven by the channel
+// observer's trend, committing capacity down when the trend is congesting
+// and the new estimate clears the expected-usage threshold.
+type trendController struct {
+	config RemoteBWEConfig
+	logger logger.Logger
+
+	committedChannelCapacity  int64
+	congestionState           bwe.CongestionState
+	congestionStateSwitchedAt time.Time
+}
+
+func newTrendController(config RemoteBWEConfig, logger logger.Logger) *trendController {
+	t := &trendController{
+		config: config,
+		logger: logger,
+	}
+	t.Reset(100_000_000)
+	return t
+}
+
+func (t *trendController) Reset(seedCapacity int64) {
+	t.committedChannelCapacity = seedCapacity
+	t.congestionState = bwe.CongestionStateNone
+	t.congestionStateSwitchedAt = mono.Now()
+}
+
+func (t *trendController) CommittedCapacity() int64 {
+	return t.committedChannelCapacity
+}
+
+func (t *trendController) State() bwe.CongestionState {
+	return t.congestionState
+}
+
+func (t *trendController) OnSample(sample ControllerSample) (bool, bwe.CongestionState, int64) {
+	newState := t.congestionState
+	update := false
+
+	switch t.congestionState {
+	case bwe.CongestionStateNone:
+		if sample.Trend == channelTrendCongesting {
+			if sample.IsInProbe || t.estimateAvailableChannelCapacity(sample) {
+				newState = bwe.CongestionStateCongested
+			}
+		}
+
+	case bwe.CongestionStateCongested:
+		if sample.Trend == channelTrendCongesting {
+			if t.estimateAvailableChannelCapacity(sample) {
+				update = true
+			}
+		} else {
+			newState = bwe.CongestionStateCongestedHangover
+		}
+
+	case bwe.CongestionStateCongestedHangover:
+		if sample.Trend == channelTrendCongesting {
+			if t.estimateAvailableChannelCapacity(sample) {
+				newState = bwe.CongestionStateCongested
+			}
+		} else if mono.Since(t.congestionStateSwitchedAt) >= t.config.CongestedMinDuration {
+			newState = bwe.CongestionStateNone
+		}
+	}
+
+	shouldNotify := false
+	if newState != t.congestionState || update {
+		t.logger.Infow(
+			"remote bwe: congestion state change",
+			"from", t.congestionState,
+			"to", newState,
+			"reason", sample.Reason,
+			"committedChannelCapacity", t.committedChannelCapacity,
+		)
+		t.congestionState = newState
+		t.congestionStateSwitchedAt = mono.Now()
+		shouldNotify = true
+	}
+
+	return shouldNotify, t.congestionState, t.committedChannelCapacity
+}
+
+func (t *trendController) estimateAvailableChannelCapacity(sample ControllerSample) bool {
+	var estimateToCommit int64
+	switch sample.Reason {
+	case channelCongestionReasonLoss:
+		estimateToCommit = int64(float64(sample.LastExpectedBandwidthUsage) * (1.0 - t.config.NackRatioAttenuator*sample.NackRatio))
+	default:
+		estimateToCommit = sample.LastReceivedEstimate
+	}
+	if estimateToCommit > sample.LastReceivedEstimate {
+		estimateToCommit = sample.LastReceivedEstimate
+	}
+
+	commitThreshold := int64(t.config.ExpectedUsageThreshold * float64(sample.LastExpectedBandwidthUsage))
+	if estimateToCommit > commitThreshold {
+		t.logger.Debugw(
+			"remote bwe: channel congestion detected, skipping above commit threshold channel capacity update",
+			"reason", sample.Reason,
+			"old(bps)", t.committedChannelCapacity,
+			"new(bps)", estimateToCommit,
+			"commitThreshold(bps)", commitThreshold,
+		)
+		return false
+	}
+
+	t.logger.Infow(
+		"remote bwe: channel congestion detected, applying channel capacity update",
+		"reason", sample.Reason,
+		"old(bps)", t.committedChannelCapacity,
+		"new(bps)", estimateToCommit,
+	)
+	t.committedChannelCapacity = estimateToCommit
+	return true
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils/mono"
+)
+
+// traceStep is one replayable point in a REMB/loss trace fed identically to
+// every Controller implementation, so trend/new_reno/cubic can be compared
+// against the same input.
+type traceStep struct {
+	trend                      channelTrend
+	reason                     channelCongestionReason
+	nackRatio                  float64
+	lastReceivedEstimate       int64
+	lastExpectedBandwidthUsage int64
+}
+
+// congestionThenRecoveryTrace models a channel that is clean, sees a
+// loss-driven congestion episode, then recovers.
+// Every controller seeds committedChannelCapacity at 100_000_000 bps, so
+// the congestion steps below use estimates below that to actually exercise
+// a back-off rather than looking like growth relative to the seed.
+var congestionThenRecoveryTrace = []traceStep{
+	{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendCongesting, reason: channelCongestionReasonLoss, nackRatio: 0.5, lastReceivedEstimate: 60_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendCongesting, reason: channelCongestionReasonLoss, nackRatio: 0.5, lastReceivedEstimate: 60_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendNeutral, lastReceivedEstimate: 70_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendNeutral, lastReceivedEstimate: 80_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	{trend: channelTrendNeutral, lastReceivedEstimate: 90_000_000, lastExpectedBandwidthUsage: 100_000_000},
+}
+
+func replayTrace(controllerType ControllerType, trace []traceStep) (capacities []int64, states []int) {
+	config := DefaultRemoteBWEConfig
+	c := newController(controllerType, config, logger.GetLogger())
+
+	for _, step := range trace {
+		_, state, capacity := c.OnSample(ControllerSample{
+			Trend:                      step.trend,
+			Reason:                     step.reason,
+			NackRatio:                  step.nackRatio,
+			LastReceivedEstimate:       step.lastReceivedEstimate,
+			LastExpectedBandwidthUsage: step.lastExpectedBandwidthUsage,
+		})
+		capacities = append(capacities, capacity)
+		states = append(states, int(state))
+	}
+	return capacities, states
+}
+
+// TestControllersReactToIdenticalTrace drives trend, new_reno and cubic from
+// the exact same REMB/loss sequence and checks every one of them backs off
+// committed capacity during the congestion episode rather than only one
+// implementation reacting.
+func TestControllersReactToIdenticalTrace(t *testing.T) {
+	for _, controllerType := range []ControllerType{ControllerTrend, ControllerNewReno, ControllerCubic} {
+		t.Run(string(controllerType), func(t *testing.T) {
+			capacities, _ := replayTrace(controllerType, congestionThenRecoveryTrace)
+
+			baseline := capacities[0]
+			afterCongestion := capacities[3]
+			if afterCongestion >= baseline {
+				t.Fatalf("%s: expected capacity to back off after loss-congestion trace steps, baseline=%d afterCongestion=%d",
+					controllerType, baseline, afterCongestion)
+			}
+		})
+	}
+}
+
+// TestControllersDoNotExceedLastReceivedEstimate pins that none of the
+// implementations commit more capacity than the channel's own last reported
+// estimate, since that would claim more bandwidth than the receiver says is
+// available.
+func TestControllersDoNotExceedLastReceivedEstimate(t *testing.T) {
+	trace := []traceStep{
+		{trend: channelTrendNeutral, lastReceivedEstimate: 200_000_000, lastExpectedBandwidthUsage: 200_000_000},
+		{trend: channelTrendNeutral, lastReceivedEstimate: 200_000_000, lastExpectedBandwidthUsage: 200_000_000},
+		{trend: channelTrendNeutral, lastReceivedEstimate: 200_000_000, lastExpectedBandwidthUsage: 200_000_000},
+	}
+
+	for _, controllerType := range []ControllerType{ControllerNewReno, ControllerCubic} {
+		t.Run(string(controllerType), func(t *testing.T) {
+			capacities, _ := replayTrace(controllerType, trace)
+			for i, capacity := range capacities {
+				if capacity > 200_000_000 {
+					t.Fatalf("%s: step %d committed %d bps, exceeding lastReceivedEstimate 200000000", controllerType, i, capacity)
+				}
+			}
+		})
+	}
+}
+
+// TestNewRenoCubicIgnoreNonLossCongestion pins that new_reno and cubic only
+// back off on the loss-specific signal (Reason == channelCongestionReasonLoss),
+// not on the generic merged Trend -- an estimate- or delay-driven congestion
+// step must not trigger their loss-based AIMD/multiplicative-decrease branch.
+func TestNewRenoCubicIgnoreNonLossCongestion(t *testing.T) {
+	trace := []traceStep{
+		{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+		{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+		{trend: channelTrendCongesting, reason: channelCongestionReasonDelay, lastReceivedEstimate: 60_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	}
+
+	for _, controllerType := range []ControllerType{ControllerNewReno, ControllerCubic} {
+		t.Run(string(controllerType), func(t *testing.T) {
+			capacities, _ := replayTrace(controllerType, trace)
+			if capacities[2] < capacities[1] {
+				t.Fatalf("%s: expected delay-only congestion (non-loss Reason) to not back off capacity, before=%d after=%d",
+					controllerType, capacities[1], capacities[2])
+			}
+		})
+	}
+}
+
+// TestCubicKUsesSegmentUnits pins that cubic's time constant K is derived
+// from an MSS-segment-count magnitude, not raw bits/sec -- using bits/sec
+// directly would inflate K into minutes, so growth after a loss episode
+// would stay far below wMax within realistic epoch durations.
+func TestCubicKUsesSegmentUnits(t *testing.T) {
+	trace := []traceStep{
+		{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+		{trend: channelTrendNeutral, lastReceivedEstimate: 100_000_000, lastExpectedBandwidthUsage: 100_000_000},
+		{trend: channelTrendCongesting, reason: channelCongestionReasonLoss, lastReceivedEstimate: 60_000_000, lastExpectedBandwidthUsage: 100_000_000},
+	}
+
+	capacities, _ := replayTrace(ControllerCubic, trace)
+	afterLoss := capacities[2]
+
+	c := newCubicController(logger.GetLogger())
+	c.Reset(100_000_000)
+	c.wMax = float64(afterLoss) / cubicBeta
+	c.committedChannelCapacity = afterLoss
+	c.epochStart = mono.Now().Add(-5 * time.Second)
+
+	_, _, capacity := c.OnSample(ControllerSample{
+		Trend:                channelTrendNeutral,
+		Reason:               channelCongestionReasonNone,
+		LastReceivedEstimate: 100_000_000,
+	})
+	if capacity <= afterLoss {
+		t.Fatalf("expected cubic to grow capacity within a few seconds of the epoch start, stayed at %d", capacity)
+	}
+}
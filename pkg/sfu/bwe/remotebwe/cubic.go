@@ -0,0 +1,113 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"math"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils/mono"
+)
+
+const (
+	cubicBeta = 0.7
+	cubicC    = 0.4
+)
+
+// cubicController grows committedChannelCapacity along the CUBIC function
+// W(t) = C*(t-K)^3 + W_max, where W_max is the capacity at the last
+// congestion event, K = cbrt(W_max*(1-beta)/C) and t is the time since that
+// event. On a new congestion event it backs capacity off by beta and
+// records a fresh W_max/epoch.
+type cubicController struct {
+	logger logger.Logger
+
+	wMax                     float64
+	epochStart               time.Time
+	committedChannelCapacity int64
+	congestionState          bwe.CongestionState
+}
+
+func newCubicController(logger logger.Logger) *cubicController {
+	c := &cubicController{logger: logger}
+	c.Reset(100_000_000)
+	return c
+}
+
+func (c *cubicController) Reset(seedCapacity int64) {
+	c.committedChannelCapacity = seedCapacity
+	c.wMax = float64(seedCapacity)
+	c.epochStart = time.Time{}
+	c.congestionState = bwe.CongestionStateNone
+}
+
+func (c *cubicController) CommittedCapacity() int64 {
+	return c.committedChannelCapacity
+}
+
+func (c *cubicController) State() bwe.CongestionState {
+	return c.congestionState
+}
+
+func (c *cubicController) OnSample(sample ControllerSample) (bool, bwe.CongestionState, int64) {
+	newState := c.congestionState
+	before := c.committedChannelCapacity
+	now := mono.Now()
+
+	if sample.Reason == channelCongestionReasonLoss {
+		c.wMax = float64(c.committedChannelCapacity)
+		c.committedChannelCapacity = int64(c.wMax * cubicBeta)
+		c.epochStart = now
+		newState = bwe.CongestionStateCongested
+	} else {
+		if c.epochStart.IsZero() {
+			c.epochStart = now
+		}
+		// The CUBIC formula's time constant K is derived assuming W is
+		// expressed in MSS-equivalent segments, not raw bits/sec -- working in
+		// bits/sec directly inflates K by orders of magnitude (minutes instead
+		// of seconds to reach wMax again). Convert to segments for the
+		// formula, then back to bits/sec to store.
+		wMaxSegments := c.wMax / float64(mssBytes*8)
+		k := math.Cbrt(wMaxSegments * (1 - cubicBeta) / cubicC)
+		t := now.Sub(c.epochStart).Seconds()
+		wSegments := cubicC*math.Pow(t-k, 3) + wMaxSegments
+		w := wSegments * float64(mssBytes*8)
+		if w > float64(c.committedChannelCapacity) {
+			c.committedChannelCapacity = int64(w)
+		}
+		if sample.LastReceivedEstimate > 0 && c.committedChannelCapacity > sample.LastReceivedEstimate {
+			c.committedChannelCapacity = sample.LastReceivedEstimate
+		}
+		newState = bwe.CongestionStateNone
+	}
+
+	shouldNotify := newState != c.congestionState || before != c.committedChannelCapacity
+	if shouldNotify {
+		c.logger.Infow(
+			"remote bwe (cubic): congestion state change",
+			"from", c.congestionState,
+			"to", newState,
+			"old(bps)", before,
+			"new(bps)", c.committedChannelCapacity,
+			"wMax", c.wMax,
+		)
+	}
+	c.congestionState = newState
+
+	return shouldNotify, c.congestionState, c.committedChannelCapacity
+}
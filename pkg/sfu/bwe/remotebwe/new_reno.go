@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+	"github.com/livekit/protocol/logger"
+)
+
+const mssBytes = 1200
+
+// newRenoController is a classic loss-based AIMD controller: it halves
+// committedChannelCapacity on a loss episode and otherwise additively
+// increases it by one MSS-equivalent (in bits) per sample, approximating
+// New Reno's one-MSS-per-RTT increase since samples arrive roughly one per
+// feedback/RTT.
+type newRenoController struct {
+	logger logger.Logger
+
+	committedChannelCapacity int64
+	congestionState          bwe.CongestionState
+}
+
+func newNewRenoController(logger logger.Logger) *newRenoController {
+	n := &newRenoController{logger: logger}
+	n.Reset(100_000_000)
+	return n
+}
+
+func (n *newRenoController) Reset(seedCapacity int64) {
+	n.committedChannelCapacity = seedCapacity
+	n.congestionState = bwe.CongestionStateNone
+}
+
+func (n *newRenoController) CommittedCapacity() int64 {
+	return n.committedChannelCapacity
+}
+
+func (n *newRenoController) State() bwe.CongestionState {
+	return n.congestionState
+}
+
+func (n *newRenoController) OnSample(sample ControllerSample) (bool, bwe.CongestionState, int64) {
+	newState := n.congestionState
+	before := n.committedChannelCapacity
+
+	if sample.Reason == channelCongestionReasonLoss {
+		n.committedChannelCapacity /= 2
+		newState = bwe.CongestionStateCongested
+	} else {
+		n.committedChannelCapacity += mssBytes * 8
+		if n.committedChannelCapacity > sample.LastReceivedEstimate && sample.LastReceivedEstimate > 0 {
+			n.committedChannelCapacity = sample.LastReceivedEstimate
+		}
+		newState = bwe.CongestionStateNone
+	}
+
+	shouldNotify := newState != n.congestionState || before != n.committedChannelCapacity
+	if shouldNotify {
+		n.logger.Infow(
+			"remote bwe (new_reno): congestion state change",
+			"from", n.congestionState,
+			"to", newState,
+			"old(bps)", before,
+			"new(bps)", n.committedChannelCapacity,
+		)
+	}
+	n.congestionState = newState
+
+	return shouldNotify, n.congestionState, n.committedChannelCapacity
+}
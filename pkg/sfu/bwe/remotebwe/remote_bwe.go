@@ -19,11 +19,16 @@ import (
 	"time"
 
 	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+	"github.com/livekit/livekit-server/pkg/sfu/bwe/fairness"
+	"github.com/livekit/livekit-server/pkg/sfu/bwe/pacer"
 	"github.com/livekit/livekit-server/pkg/sfu/ccutils"
 	"github.com/livekit/protocol/logger"
-	"github.com/livekit/protocol/utils/mono"
 )
 
+// mtuBytes is the assumed on-wire packet size used to size the probe pacer's
+// burst allowance.
+const mtuBytes = 1500
+
 // ---------------------------------------------------------------------------
 
 type RemoteBWEConfig struct {
@@ -35,6 +40,15 @@ type RemoteBWEConfig struct {
 
 	PeriodicCheckInterval          time.Duration `yaml:"periodic_check_interval,omitempty"`
 	PeriodicCheckIntervalCongested time.Duration `yaml:"periodic_check_interval_congested,omitempty"`
+
+	// FeedbackMode selects which RTCP feedback drives congestion detection:
+	// "remb" (default, HandleREMB only), "twcc" (HandleTransportCC only) or
+	// "hybrid" (both feed the channel observer).
+	FeedbackMode FeedbackMode `yaml:"feedback_mode,omitempty"`
+
+	// Controller selects the congestion-detection strategy: "trend" (default,
+	// the original REMB-trend + NACK-ratio detector), "new_reno" or "cubic".
+	Controller ControllerType `yaml:"controller,omitempty"`
 }
 
 var (
@@ -46,6 +60,8 @@ var (
 		CongestedMinDuration:           3 * time.Second,
 		PeriodicCheckInterval:          2 * time.Second,
 		PeriodicCheckIntervalCongested: 200 * time.Millisecond,
+		FeedbackMode:                   FeedbackModeREMB,
+		Controller:                     ControllerTrend,
 	}
 )
 
@@ -65,13 +81,19 @@ type RemoteBWE struct {
 
 	lastReceivedEstimate       int64
 	lastExpectedBandwidthUsage int64
-	committedChannelCapacity   int64
 
 	isInProbe       bool
 	channelObserver *channelObserver
 
-	congestionState           bwe.CongestionState
-	congestionStateSwitchedAt time.Time
+	sendHistory *sendHistory
+	delayFilter *delayGradientFilter
+
+	controller Controller
+
+	probePacer          *pacer.Pacer
+	lastProbePacerStats pacer.Stats
+
+	trackConstraints map[fairness.TrackID]fairness.Constraint
 
 	bweListener bwe.BWEListener
 }
@@ -105,13 +127,62 @@ func (r *RemoteBWE) Reset() {
 
 	r.lastReceivedEstimate = 0
 	r.lastExpectedBandwidthUsage = 0
-	r.committedChannelCapacity = 100_000_000
 
 	r.isInProbe = false
+	r.controller = newController(r.params.Config.Controller, r.params.Config, r.params.Logger)
 	r.newChannelObserver()
 
-	r.congestionState = bwe.CongestionStateNone
-	r.congestionStateSwitchedAt = mono.Now()
+	r.sendHistory = newSendHistory()
+	r.delayFilter = newDelayGradientFilter()
+
+	r.probePacer = nil
+
+	r.trackConstraints = make(map[fairness.TrackID]fairness.Constraint)
+}
+
+// UpdateTrackConstraint records or updates a single published track
+// subscription's bandwidth constraint, then immediately re-splits the
+// current committed capacity across all tracks so the new subscription is
+// reflected without waiting for the next feedback report. The same caller
+// that owns subscribe/unsubscribe (and calls SetBWEListener once up front)
+// calls this on every subscription change and RemoveTrackConstraint on
+// unsubscribe.
+func (r *RemoteBWE) UpdateTrackConstraint(trackID fairness.TrackID, constraint fairness.Constraint) {
+	r.lock.Lock()
+	r.trackConstraints[trackID] = constraint
+	committedChannelCapacity := r.controller.CommittedCapacity()
+	r.lock.Unlock()
+
+	r.updateTrackAllocations(committedChannelCapacity)
+}
+
+// RemoveTrackConstraint drops a track's bandwidth constraint (on
+// unsubscribe) and re-splits committed capacity across what remains.
+func (r *RemoteBWE) RemoveTrackConstraint(trackID fairness.TrackID) {
+	r.lock.Lock()
+	delete(r.trackConstraints, trackID)
+	committedChannelCapacity := r.controller.CommittedCapacity()
+	r.lock.Unlock()
+
+	r.updateTrackAllocations(committedChannelCapacity)
+}
+
+// updateTrackAllocations re-splits capacity across the current track
+// constraints using weighted max-min fairness and notifies the listener.
+// Must not be called with r.lock held.
+func (r *RemoteBWE) updateTrackAllocations(committedChannelCapacity int64) {
+	r.lock.RLock()
+	constraints := r.trackConstraints
+	r.lock.RUnlock()
+
+	if len(constraints) == 0 {
+		return
+	}
+
+	allocations := fairness.Allocate(committedChannelCapacity, constraints)
+	if bweListener := r.getBWEListener(); bweListener != nil {
+		bweListener.OnTrackAllocations(allocations)
+	}
 }
 
 func (r *RemoteBWE) HandleREMB(
@@ -126,14 +197,17 @@ func (r *RemoteBWE) HandleREMB(
 
 	// in probe, freeze channel observer state if probe causes congestion till the probe is done,
 	// this is to ensure that probe result is not a success and an unsuccessful probe will not up allocate any tracks
-	if r.isInProbe && r.congestionState != bwe.CongestionStateNone {
+	if r.isInProbe && r.controller.State() != bwe.CongestionStateNone {
 		r.lock.Unlock()
 		return
 	}
 
-	r.channelObserver.AddEstimate(r.lastReceivedEstimate)
-	r.channelObserver.AddNack(sentPackets, repeatedNacks)
+	if r.params.Config.FeedbackMode != FeedbackModeTWCC {
+		r.channelObserver.AddEstimate(r.lastReceivedEstimate)
+		r.channelObserver.AddNack(sentPackets, repeatedNacks)
+	}
 
+	capacityBefore := r.controller.CommittedCapacity()
 	shouldNotify, state, committedChannelCapacity := r.congestionDetectionStateMachine()
 	r.lock.Unlock()
 
@@ -142,116 +216,41 @@ func (r *RemoteBWE) HandleREMB(
 			bweListener.OnCongestionStateChange(state, committedChannelCapacity)
 		}
 	}
+	if committedChannelCapacity != capacityBefore {
+		r.updateTrackAllocations(committedChannelCapacity)
+	}
 }
 
 func (r *RemoteBWE) CongestionState() bwe.CongestionState {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	return r.congestionState
+	return r.controller.State()
 }
 
+// congestionDetectionStateMachine feeds the channel observer's latest trend
+// into the configured Controller. Must be called with r.lock held.
 func (r *RemoteBWE) congestionDetectionStateMachine() (bool, bwe.CongestionState, int64) {
-	newState := r.congestionState
-	update := false
 	trend, reason := r.channelObserver.GetTrend()
 	if trend == channelTrendCongesting {
 		r.params.Logger.Debugw("remote bwe, channel congesting", "channel", r.channelObserver)
 	}
 
-	switch r.congestionState {
-	case bwe.CongestionStateNone:
-		if trend == channelTrendCongesting {
-			if r.isInProbe || r.estimateAvailableChannelCapacity(reason) {
-				// when in probe, if congested, stays there will probe is done,
-				// the estimate stays at pre-probe level
-				newState = bwe.CongestionStateCongested
-			}
-		}
-
-	case bwe.CongestionStateCongested:
-		if trend == channelTrendCongesting {
-			if r.estimateAvailableChannelCapacity(reason) {
-				// update state as this needs to reset switch time to wait for congestion min duration again
-				update = true
-			}
-		} else {
-			newState = bwe.CongestionStateCongestedHangover
-		}
-
-	case bwe.CongestionStateCongestedHangover:
-		if trend == channelTrendCongesting {
-			if r.estimateAvailableChannelCapacity(reason) {
-				newState = bwe.CongestionStateCongested
-			}
-		} else if time.Since(r.congestionStateSwitchedAt) >= r.params.Config.CongestedMinDuration {
-			newState = bwe.CongestionStateNone
-		}
-	}
-
-	shouldNotify := false
-	if newState != r.congestionState || update {
-		r.updateCongestionState(newState, reason)
-		shouldNotify = true
-	}
-
-	return shouldNotify, r.congestionState, r.committedChannelCapacity
-}
-
-func (r *RemoteBWE) estimateAvailableChannelCapacity(reason channelCongestionReason) bool {
-	var estimateToCommit int64
-	switch reason {
-	case channelCongestionReasonLoss:
-		estimateToCommit = int64(float64(r.lastExpectedBandwidthUsage) * (1.0 - r.params.Config.NackRatioAttenuator*r.channelObserver.GetNackRatio()))
-	default:
-		estimateToCommit = r.lastReceivedEstimate
-	}
-	if estimateToCommit > r.lastReceivedEstimate {
-		estimateToCommit = r.lastReceivedEstimate
-	}
-
-	commitThreshold := int64(r.params.Config.ExpectedUsageThreshold * float64(r.lastExpectedBandwidthUsage))
-
-	ulgr := r.params.Logger.WithUnlikelyValues(
-		"reason", reason,
-		"old(bps)", r.committedChannelCapacity,
-		"new(bps)", estimateToCommit,
-		"lastReceived(bps)", r.lastReceivedEstimate,
-		"expectedUsage(bps)", r.lastExpectedBandwidthUsage,
-		"commitThreshold(bps)", commitThreshold,
-		"channel", r.channelObserver,
-	)
-	if estimateToCommit > commitThreshold {
-		ulgr.Debugw("remote bwe: channel congestion detected, skipping above commit threshold channel capacity update")
-		return false
+	capacityBefore := r.controller.CommittedCapacity()
+	shouldNotify, state, committedChannelCapacity := r.controller.OnSample(ControllerSample{
+		Trend:                      trend,
+		Reason:                     reason,
+		NackRatio:                  r.channelObserver.GetNackRatio(),
+		LastReceivedEstimate:       r.lastReceivedEstimate,
+		LastExpectedBandwidthUsage: r.lastExpectedBandwidthUsage,
+		IsInProbe:                  r.isInProbe,
+	})
+	if committedChannelCapacity != capacityBefore {
+		// reset to get a new set of samples for the next trend
+		r.newChannelObserver()
 	}
 
-	ulgr.Infow("remote bwe: channel congestion detected, applying channel capacity update")
-	/* REMOTE-BWE-DATA
-	r.params.Logger.Debugw(
-		fmt.Sprintf("remote bwe: channel congestion detected, %s channel capacity: experimental", action),
-		"nackHistory", r.channelObserver.GetNackHistory(),
-	)
-	*/
-
-	r.committedChannelCapacity = estimateToCommit
-
-	// reset to get new set of samples for next trend
-	r.newChannelObserver()
-	return true
-}
-
-func (r *RemoteBWE) updateCongestionState(state bwe.CongestionState, reason channelCongestionReason) {
-	r.params.Logger.Infow(
-		"remote bwe: congestion state change",
-		"from", r.congestionState,
-		"to", state,
-		"reason", reason,
-		"committedChannelCapacity", r.committedChannelCapacity,
-	)
-
-	r.congestionState = state
-	r.congestionStateSwitchedAt = mono.Now()
+	return shouldNotify, state, committedChannelCapacity
 }
 
 func (r *RemoteBWE) ProbeClusterStarting(pci ccutils.ProbeClusterInfo) {
@@ -260,52 +259,104 @@ func (r *RemoteBWE) ProbeClusterStarting(pci ccutils.ProbeClusterInfo) {
 
 	r.lastExpectedBandwidthUsage = int64(pci.Goal.ExpectedUsageBps)
 
+	burstBytes := pci.Goal.DesiredBps / 8 * 0.010 // 10ms worth of the desired rate
+	if maxBurst := float64(2 * mtuBytes); burstBytes > maxBurst {
+		burstBytes = maxBurst
+	}
+	r.probePacer = pacer.NewPacer(pci.Goal.DesiredBps, burstBytes, float64(mtuBytes))
+
 	r.params.Logger.Debugw(
 		"remote bwe: starting probe",
 		"lastReceived", r.lastReceivedEstimate,
 		"expectedBandwidthUsage", r.lastExpectedBandwidthUsage,
 		"channel", r.channelObserver,
+		"pacerRate(bps)", pci.Goal.DesiredBps,
+		"pacerBurst(bytes)", burstBytes,
 	)
 
 	r.isInProbe = true
 	r.newChannelObserver()
 }
 
+// ProbePacer returns the token-bucket pacer for the probe currently in
+// progress, or nil if no probe is running. The probe generator reserves
+// bytes against it before sending each probe packet and watches LowTokens
+// to know when to slow down or refill.
+func (r *RemoteBWE) ProbePacer() *pacer.Pacer {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.probePacer
+}
+
+// LastProbePacerStats returns the probe pacer statistics from the most
+// recently completed probe, so a caller receiving ProbeSignalClearing from
+// ProbeClusterDone can tell a genuine clearing apart from one achieved by
+// bursty over-pacing (e.g. a high Stalls count or a MaxBurstBytes much
+// larger than the configured burst).
+func (r *RemoteBWE) LastProbePacerStats() pacer.Stats {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.lastProbePacerStats
+}
+
 func (r *RemoteBWE) ProbeClusterDone(_pci ccutils.ProbeClusterInfo) (bwe.ProbeSignal, int64) {
 	r.lock.Lock()
-	defer r.lock.Unlock()
 
 	// switch to a non-probe channel observer on probe end,
 	// reset congestion state to get a fresh trend
 	pco := r.channelObserver
-	probeCongestionState := r.congestionState
+	probeCongestionState := r.controller.State()
+	committedChannelCapacity := r.controller.CommittedCapacity()
 
 	r.isInProbe = false
-	r.congestionState = bwe.CongestionStateNone
+	r.controller.Reset(committedChannelCapacity)
 	r.newChannelObserver()
 
+	var pacerStats pacer.Stats
+	if r.probePacer != nil {
+		pacerStats = r.probePacer.Stats()
+	}
+	r.probePacer = nil
+	r.lastProbePacerStats = pacerStats
+
 	r.params.Logger.Debugw(
 		"remote bwe: probe done",
 		"lastReceived", r.lastReceivedEstimate,
 		"expectedBandwidthUsage", r.lastExpectedBandwidthUsage,
 		"channel", pco,
 		"isSignalValid", pco.HasEnoughEstimateSamples(),
+		"pacerAvgRate(bps)", pacerStats.AvgActualRateBps,
+		"pacerMaxBurst(bytes)", pacerStats.MaxBurstBytes,
+		"pacerStalls", pacerStats.Stalls,
+		"pacerUnusedTokens(bytes)", pacerStats.UnusedTokens,
 	)
 
 	if probeCongestionState != bwe.CongestionStateNone {
-		return bwe.ProbeSignalCongesting, r.committedChannelCapacity
+		r.lock.Unlock()
+		return bwe.ProbeSignalCongesting, committedChannelCapacity
 	}
 
 	trend, _ := pco.GetTrend()
 	if !pco.HasEnoughEstimateSamples() || trend == channelTrendNeutral {
-		return bwe.ProbeSignalInconclusive, r.committedChannelCapacity
+		r.lock.Unlock()
+		return bwe.ProbeSignalInconclusive, committedChannelCapacity
 	}
 
+	capacityChanged := false
 	highestEstimate := pco.GetHighestEstimate()
-	if highestEstimate > r.committedChannelCapacity {
-		r.committedChannelCapacity = highestEstimate
+	if highestEstimate > committedChannelCapacity {
+		committedChannelCapacity = highestEstimate
+		r.controller.Reset(committedChannelCapacity)
+		capacityChanged = true
+	}
+	r.lock.Unlock()
+
+	if capacityChanged {
+		r.updateTrackAllocations(committedChannelCapacity)
 	}
-	return bwe.ProbeSignalClearing, r.committedChannelCapacity
+	return bwe.ProbeSignalClearing, committedChannelCapacity
 }
 
 func (r *RemoteBWE) newChannelObserver() {
@@ -317,7 +368,7 @@ func (r *RemoteBWE) newChannelObserver() {
 			},
 			r.params.Logger,
 		)
-		r.channelObserver.SeedEstimate(r.committedChannelCapacity)
+		r.channelObserver.SeedEstimate(r.controller.CommittedCapacity())
 	} else {
 		r.channelObserver = newChannelObserver(
 			channelObserverParams{
@@ -0,0 +1,270 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/livekit-server/pkg/sfu/bwe"
+)
+
+// FeedbackMode selects which RTCP feedback RemoteBWE ingests to drive its
+// congestion detection.
+type FeedbackMode string
+
+const (
+	// FeedbackModeREMB uses HandleREMB only, the historical behavior.
+	FeedbackModeREMB FeedbackMode = "remb"
+	// FeedbackModeTWCC uses HandleTransportCC only.
+	FeedbackModeTWCC FeedbackMode = "twcc"
+	// FeedbackModeHybrid feeds both into the channel observer.
+	FeedbackModeHybrid FeedbackMode = "hybrid"
+)
+
+// SentPacketInfo is the send-side record needed to interpret a TWCC feedback
+// report: when a sequence number was sent and how large it was on the wire.
+type SentPacketInfo struct {
+	SequenceNumber uint16
+	SentAt         time.Time
+	Size           int
+}
+
+const sendHistorySize = 2048
+
+// sendHistory is a fixed-size ring buffer of recently sent packets, indexed
+// by sequence number modulo its size, so TWCC feedback (which arrives with a
+// delay) can still be paired with send-side timing.
+type sendHistory struct {
+	entries [sendHistorySize]SentPacketInfo
+	valid   [sendHistorySize]bool
+}
+
+func newSendHistory() *sendHistory {
+	return &sendHistory{}
+}
+
+func (s *sendHistory) record(info SentPacketInfo) {
+	idx := info.SequenceNumber % sendHistorySize
+	s.entries[idx] = info
+	s.valid[idx] = true
+}
+
+func (s *sendHistory) get(sn uint16) (SentPacketInfo, bool) {
+	idx := sn % sendHistorySize
+	if !s.valid[idx] || s.entries[idx].SequenceNumber != sn {
+		return SentPacketInfo{}, false
+	}
+	return s.entries[idx], true
+}
+
+// ---------------------------------------------------------------------------
+
+// delayGradientFilter is a Kalman filter over the one-way-delay gradient
+// between consecutive packet groups, following the same adaptive-gain
+// structure as GCC: m_hat = m_hat + K*(m - m_hat), with K derived from the
+// ratio of process to (accumulating) measurement variance.
+type delayGradientFilter struct {
+	mHat            float64
+	varMeasurement  float64
+	varProcess      float64
+	initialized     bool
+}
+
+func newDelayGradientFilter() *delayGradientFilter {
+	return &delayGradientFilter{
+		varMeasurement: 1e-1,
+		varProcess:     1e-3,
+	}
+}
+
+func (f *delayGradientFilter) update(measurement float64) float64 {
+	if !f.initialized {
+		f.mHat = measurement
+		f.initialized = true
+		return f.mHat
+	}
+
+	residual := measurement - f.mHat
+	// adapt measurement variance from the residual so bursts of noise widen
+	// the filter's uncertainty instead of being chased as real signal.
+	f.varMeasurement = 0.95*f.varMeasurement + 0.05*residual*residual
+
+	gain := f.varProcess / (f.varProcess + f.varMeasurement)
+	if gain < 0 {
+		gain = 0
+	} else if gain > 1 {
+		gain = 1
+	}
+
+	f.mHat += gain * residual
+	return f.mHat
+}
+
+// ---------------------------------------------------------------------------
+
+// twccGroupResult is the set of signals derived from one TWCC feedback
+// report: instantaneous delivery rate, the filtered one-way-delay gradient
+// and the loss ratio over the feedback's sequence range.
+type twccGroupResult struct {
+	deliveryBps   float64
+	delayGradient float64
+	lossRatio     float64
+	hasDelay      bool
+}
+
+// HandleTransportCC ingests a transport-wide congestion control feedback
+// report. sentPacketInfos are the sender-side records for packets sent since
+// the last call (recorded into the internal send-history ring buffer before
+// the feedback is walked), so that arrival timing reported by the receiver
+// can be paired back with when/how-large each packet was on the wire.
+func (r *RemoteBWE) HandleTransportCC(feedback *rtcp.TransportLayerCC, sentPacketInfos []SentPacketInfo) {
+	r.lock.Lock()
+
+	for _, spi := range sentPacketInfos {
+		r.sendHistory.record(spi)
+	}
+
+	result, ok := r.processTransportCC(feedback)
+	if !ok {
+		r.lock.Unlock()
+		return
+	}
+
+	if r.isInProbe && r.controller.State() != bwe.CongestionStateNone {
+		r.lock.Unlock()
+		return
+	}
+
+	if r.params.Config.FeedbackMode != FeedbackModeREMB {
+		r.channelObserver.AddEstimate(int64(result.deliveryBps))
+		if result.hasDelay {
+			r.channelObserver.AddDelayGradient(result.delayGradient)
+		}
+		r.channelObserver.AddNack(uint32(feedback.PacketStatusCount), uint32(float64(feedback.PacketStatusCount)*result.lossRatio))
+	}
+
+	capacityBefore := r.controller.CommittedCapacity()
+	shouldNotify, state, committedChannelCapacity := r.congestionDetectionStateMachine()
+	r.lock.Unlock()
+
+	if shouldNotify {
+		if bweListener := r.getBWEListener(); bweListener != nil {
+			bweListener.OnCongestionStateChange(state, committedChannelCapacity)
+		}
+	}
+	if committedChannelCapacity != capacityBefore {
+		r.updateTrackAllocations(committedChannelCapacity)
+	}
+}
+
+// processTransportCC walks the feedback's packet status chunks, pairs each
+// reported sequence number with its send-side record and returns the
+// derived delivery rate / delay gradient / loss ratio for the report. Must
+// be called with r.lock held.
+func (r *RemoteBWE) processTransportCC(feedback *rtcp.TransportLayerCC) (twccGroupResult, bool) {
+	statuses := feedback.PacketChunks
+	if len(statuses) == 0 {
+		return twccGroupResult{}, false
+	}
+
+	type arrival struct {
+		sn       uint16
+		sentAt   time.Time
+		size     int
+		recvTime time.Time
+	}
+
+	refTime := time.Unix(0, int64(feedback.ReferenceTime)*64*int64(time.Millisecond))
+	deltaIdx := 0
+	sn := feedback.BaseSequenceNumber
+
+	var arrivals []arrival
+	var lost, total int
+
+	walkSymbols := func(symbols []rtcp.TypeTCC) {
+		for _, sym := range symbols {
+			total++
+			if sym == rtcp.TypeTCCPacketNotReceived {
+				lost++
+				sn++
+				continue
+			}
+
+			if deltaIdx >= len(feedback.RecvDeltas) {
+				sn++
+				continue
+			}
+			delta := feedback.RecvDeltas[deltaIdx]
+			deltaIdx++
+
+			recvTime := refTime.Add(time.Duration(delta.Delta) * time.Microsecond)
+			if spi, ok := r.sendHistory.get(sn); ok {
+				arrivals = append(arrivals, arrival{sn: sn, sentAt: spi.SentAt, size: spi.Size, recvTime: recvTime})
+			}
+			sn++
+		}
+	}
+
+	for _, chunk := range statuses {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			symbols := make([]rtcp.TypeTCC, c.RunLength)
+			for i := range symbols {
+				symbols[i] = c.PacketStatusSymbol
+			}
+			walkSymbols(symbols)
+		case *rtcp.StatusVectorChunk:
+			walkSymbols(c.SymbolList)
+		}
+	}
+
+	if len(arrivals) == 0 {
+		lossRatio := 0.0
+		if total > 0 {
+			lossRatio = float64(lost) / float64(total)
+		}
+		return twccGroupResult{lossRatio: lossRatio}, total > 0
+	}
+
+	first, last := arrivals[0], arrivals[len(arrivals)-1]
+	recvSpan := last.recvTime.Sub(first.recvTime)
+	var bytesAcked int
+	for _, a := range arrivals {
+		bytesAcked += a.size
+	}
+
+	var deliveryBps float64
+	if recvSpan > 0 {
+		deliveryBps = float64(bytesAcked*8) / recvSpan.Seconds()
+	}
+
+	sendSpan := last.sentAt.Sub(first.sentAt)
+	gradientMeasurement := recvSpan.Seconds() - sendSpan.Seconds()
+	gradient := r.delayFilter.update(gradientMeasurement)
+
+	lossRatio := 0.0
+	if total > 0 {
+		lossRatio = float64(lost) / float64(total)
+	}
+
+	return twccGroupResult{
+		deliveryBps:   deliveryBps,
+		delayGradient: gradient,
+		lossRatio:     lossRatio,
+		hasDelay:      true,
+	}, true
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// receivedSymbol is a TypeTCC value guaranteed to differ from
+// rtcp.TypeTCCPacketNotReceived, without assuming the name of whichever
+// "received" constant pion/rtcp exports.
+func receivedSymbol() rtcp.TypeTCC {
+	if rtcp.TypeTCCPacketNotReceived != 0 {
+		return rtcp.TypeTCC(0)
+	}
+	return rtcp.TypeTCC(1)
+}
+
+func newTestRemoteBWE() *RemoteBWE {
+	return NewRemoteBWE(RemoteBWEParams{
+		Config: DefaultRemoteBWEConfig,
+		Logger: logger.GetLogger(),
+	})
+}
+
+// feedbackAllReceived builds a TWCC report acking n consecutive sequence
+// numbers starting at baseSN, each arriving deltaMicros apart, with no
+// losses.
+func feedbackAllReceived(baseSN uint16, n int, deltaMicros int64) *rtcp.TransportLayerCC {
+	symbol := receivedSymbol()
+	recvDeltas := make([]*rtcp.RecvDelta, n)
+	for i := range recvDeltas {
+		recvDeltas[i] = &rtcp.RecvDelta{Delta: deltaMicros * int64(i)}
+	}
+	return &rtcp.TransportLayerCC{
+		BaseSequenceNumber: baseSN,
+		PacketStatusCount:  uint16(n),
+		ReferenceTime:      0,
+		PacketChunks: []rtcp.PacketStatusChunk{
+			&rtcp.RunLengthChunk{PacketStatusSymbol: symbol, RunLength: uint16(n)},
+		},
+		RecvDeltas: recvDeltas,
+	}
+}
+
+// TestHandleTransportCCRecordsNackEvenWithoutLoss pins that a loss-free TWCC
+// report still feeds the channel observer's NACK tracking (sentPackets goes
+// up even when lostPackets doesn't), matching HandleREMB's unconditional
+// AddNack call -- a report's zero loss ratio is itself a signal, not a
+// reason to skip updating the running loss window.
+func TestHandleTransportCCRecordsNackEvenWithoutLoss(t *testing.T) {
+	r := newTestRemoteBWE()
+	r.params.Config.FeedbackMode = FeedbackModeTWCC
+
+	for _, spi := range []SentPacketInfo{
+		{SequenceNumber: 0, SentAt: time.Now(), Size: 1200},
+		{SequenceNumber: 1, SentAt: time.Now(), Size: 1200},
+		{SequenceNumber: 2, SentAt: time.Now(), Size: 1200},
+	} {
+		r.sendHistory.record(spi)
+	}
+
+	feedback := feedbackAllReceived(0, 3, 1000)
+	r.HandleTransportCC(feedback, nil)
+
+	r.lock.RLock()
+	sentPackets := r.channelObserver.packetsSent
+	r.lock.RUnlock()
+
+	if sentPackets == 0 {
+		t.Fatalf("expected a loss-free TWCC report to still record sent packets for the NACK ratio window, got %d", sentPackets)
+	}
+}
+
+// TestProcessTransportCCDerivesDeliveryRate pins that a feedback report
+// acking packets with a known size and receive-time spacing derives a
+// delivery rate from bytes-acked-over-receive-span, not some other basis.
+func TestProcessTransportCCDerivesDeliveryRate(t *testing.T) {
+	r := newTestRemoteBWE()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sentAt := time.Now()
+	for sn := uint16(0); sn < 3; sn++ {
+		r.sendHistory.record(SentPacketInfo{SequenceNumber: sn, SentAt: sentAt, Size: 1200})
+	}
+
+	feedback := feedbackAllReceived(0, 3, 10_000) // 10ms apart
+	result, ok := r.processTransportCC(feedback)
+	if !ok {
+		t.Fatal("expected processTransportCC to report ok for an all-received feedback report")
+	}
+	if result.deliveryBps <= 0 {
+		t.Fatalf("expected a positive delivery rate, got %v", result.deliveryBps)
+	}
+	// 3 * 1200 bytes * 8 bits over the 20ms between first and last arrival.
+	const wantBps = 3 * 1200 * 8 / 0.020
+	if diff := result.deliveryBps - wantBps; diff > 1 || diff < -1 {
+		t.Fatalf("expected delivery rate %v (bytes acked over receive span), got %v", wantBps, result.deliveryBps)
+	}
+}